@@ -1,23 +1,22 @@
 package client
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/BrunoKrugel/snapshot2stream/internal/config"
+	"github.com/BrunoKrugel/snapshot2stream/internal/model"
+	"github.com/BrunoKrugel/snapshot2stream/internal/utils"
 	"github.com/go-resty/resty/v2"
 )
 
 type Client struct {
 	restyClient *resty.Client
-	authToken   string
-	cookieName  string
-	cookieValue string
 }
 
-func NewRestyClient(cfg *config.Config) *Client {
-
+func NewRestyClient() *Client {
 	restyClient := resty.New().
 		SetTimeout(5*time.Second).
 		SetHeader("User-Agent", "app/1 CFNetwork/3826.600.41 Darwin/24.6.0").
@@ -36,34 +35,99 @@ func NewRestyClient(cfg *config.Config) *Client {
 	}
 	restyClient.SetTransport(transport)
 
-	cookieName, cookieValue := parseCookie(cfg.Authorization.Cookie)
-
-	return &Client{
-		restyClient: restyClient,
-		authToken:   cfg.Authorization.Token,
-		cookieName:  cookieName,
-		cookieValue: cookieValue,
-	}
+	return &Client{restyClient: restyClient}
 }
 
-func (c *Client) GetStream(url string) (*resty.Response, error) {
+// GetStream fetches a single snapshot from url, applying authToken and a
+// cookie named cookieName if given. Credentials are per-call rather than
+// baked into the Client since each camera now carries its own.
+func (c *Client) GetStream(url, authToken, cookieName, cookieValue string) (*resty.Response, error) {
 	req := c.restyClient.R()
-	
-	if c.authToken != "" {
-		req.SetHeader("Authorization", c.authToken)
+
+	if authToken != "" {
+		req.SetHeader("Authorization", authToken)
 	}
-	
-	if c.cookieValue != "" {
+
+	if cookieValue != "" {
 		req.SetCookie(&http.Cookie{
-			Name:  c.cookieName,
-			Value: c.cookieValue,
+			Name:  cookieName,
+			Value: cookieValue,
 		})
 	}
-	
+
 	return req.Get(url)
 }
 
-func parseCookie(s string) (name, value string) {
+// Source is implemented by anything that can produce frames for a camera,
+// whether by polling an HTTP snapshot endpoint, pulling from an RTSP
+// session, or decoding a local file in the future. FrameManager writes
+// frames from any Source into its cache through the same fetch path.
+type Source interface {
+	Fetch(ctx context.Context) (*model.Frame, error)
+	Close() error
+}
+
+// SnapshotSource is a Source that fetches frames by polling a JPEG
+// snapshot URL over HTTP.
+type SnapshotSource struct {
+	client      *Client
+	url         string
+	authToken   string
+	cookieName  string
+	cookieValue string
+}
+
+// NewSnapshotSource builds a SnapshotSource for one camera. cookie is
+// parsed the same way as the old cookie env var: "name=value", or a bare
+// value that defaults to the "SessaoId" name this app's cameras expect.
+func NewSnapshotSource(client *Client, url, authToken, cookie string) *SnapshotSource {
+	cookieName, cookieValue := ParseCookie(cookie)
+	return &SnapshotSource{
+		client:      client,
+		url:         url,
+		authToken:   authToken,
+		cookieName:  cookieName,
+		cookieValue: cookieValue,
+	}
+}
+
+func (s *SnapshotSource) Fetch(ctx context.Context) (*model.Frame, error) {
+	resp, err := s.client.GetStream(s.url, s.authToken, s.cookieName, s.cookieValue)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.RawResponse != nil && resp.RawResponse.Body != nil {
+		defer resp.RawResponse.Body.Close()
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("bad status: %s", resp.Status())
+	}
+
+	body := resp.Body()
+	if len(body) == 0 {
+		return nil, fmt.Errorf("empty body")
+	}
+
+	if !utils.IsValidJPEG(body) {
+		return nil, fmt.Errorf("invalid JPEG frame")
+	}
+
+	data := make([]byte, len(body))
+	copy(data, body)
+
+	return &model.Frame{Data: data, Timestamp: time.Now()}, nil
+}
+
+// Close is a no-op: snapshot polling holds no session between fetches.
+func (s *SnapshotSource) Close() error {
+	return nil
+}
+
+// ParseCookie splits a "name=value" cookie string, defaulting to the
+// "SessaoId" name used by this app's cameras when no name is given.
+func ParseCookie(s string) (name, value string) {
 	if s == "" {
 		return "", ""
 	}
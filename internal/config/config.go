@@ -1,44 +1,123 @@
 package config
 
 import (
-	"github.com/caarlos0/env/v9"
+	"fmt"
+	"log"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
 )
 
+// InputMode selects how a camera's frames are produced.
+type InputMode string
+
+const (
+	// InputModeSnapshot polls a JPEG snapshot URL over HTTP (the default).
+	InputModeSnapshot InputMode = "SNAPSHOT"
+	// InputModeRTSP pulls decoded frames from a persistent RTSP session.
+	InputModeRTSP InputMode = "RTSP"
+)
+
+// Config is the whole app's configuration: server-wide knobs plus the
+// list of cameras to serve.
 type Config struct {
-	Authorization Authorization
-	Cameras       Cameras
-	Server        Server
+	Server  Server
+	Cameras []CameraConfig
 }
 
-type Authorization struct {
-	Cookie string `env:"cookie"`
-	Token  string `env:"token"`
+// CameraConfig describes a single camera entry from the cameras: list in
+// config.yaml. Unlike the old fixed Camera1..Camera6 env vars, cameras
+// can be added, removed, or edited at runtime by editing the file.
+type CameraConfig struct {
+	Name      string    `mapstructure:"name"`
+	URL       string    `mapstructure:"url"`
+	InputMode InputMode `mapstructure:"input_mode"`
+	AuthToken string    `mapstructure:"auth_token"`
+	Cookie    string    `mapstructure:"cookie"`
+	FetchFPS  int       `mapstructure:"fetch_fps"`
+	CacheSize int       `mapstructure:"cache_size"`
+	Enabled   bool      `mapstructure:"enabled"`
+	MaxThread int       `mapstructure:"max_thread"`
 }
 
-type Cameras struct {
-	Camera1 string `env:"camera1"`
-	Camera2 string `env:"camera2"`
-	Camera3 string `env:"camera3"`
-	Camera4 string `env:"camera4"`
-	Camera5 string `env:"camera5"`
-	Camera6 string `env:"camera6"`
+type Server struct {
+	Port     string `mapstructure:"port"`
+	LogLevel string `mapstructure:"log_level"`
+	FPS      int    `mapstructure:"fps"`
+	FetchFPS int    `mapstructure:"fetch_fps"`
+	UseCache bool   `mapstructure:"use_cache"`
+
+	// HLS output, served alongside the MJPEG endpoint.
+	HLSEnabled         bool `mapstructure:"hls_enabled"`
+	HLSSegmentDuration int  `mapstructure:"hls_segment_duration"` // seconds
+	HLSSegmentCount    int  `mapstructure:"hls_segment_count"`
+
+	// On-disk recording/NVR subsystem. RecordingMaxDiskBytes is a per-camera
+	// budget, not a total across all cameras: N enabled cameras can use up
+	// to N*RecordingMaxDiskBytes on disk.
+	RecordingEnabled      bool   `mapstructure:"recording_enabled"`
+	RecordingDir          string `mapstructure:"recording_dir"`
+	RecordingMaxDiskBytes int64  `mapstructure:"recording_max_disk_bytes"`
+	RecordingMaxAgeHours  int    `mapstructure:"recording_max_age_hours"`
 }
 
-type Server struct {
-	Port     string `env:"PORT" envDefault:"8081"`
-	LogLevel string `env:"LOG_LEVEL" envDefault:"info"`
-	FPS      int    `env:"FPS" envDefault:"10"`
-	FetchFPS int    `env:"FETCH_FPS" envDefault:"30"`
-	UseCache bool   `env:"USE_CACHE" envDefault:"true"`
+// Loader owns the viper instance backing config.yaml so callers can get a
+// freshly decoded Config and watch the file for edits.
+type Loader struct {
+	v *viper.Viper
 }
 
-// TODO: Use viper and parse from config.yaml
-func NewConfig() (*Config, error) {
-	cfg := &Config{}
-	err := env.Parse(cfg)
-	if err != nil {
-		return cfg, err
+// NewLoader reads path (a config.yaml) and binds the server-wide knobs to
+// their historical env vars so existing deployments keep working.
+func NewLoader(path string) (*Loader, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.AutomaticEnv()
+
+	v.SetDefault("server.port", "8081")
+	v.SetDefault("server.log_level", "info")
+	v.SetDefault("server.fps", 10)
+	v.SetDefault("server.fetch_fps", 30)
+	v.SetDefault("server.use_cache", true)
+	v.SetDefault("server.hls_segment_duration", 4)
+	v.SetDefault("server.hls_segment_count", 6)
+	v.SetDefault("server.recording_dir", "./recordings")
+	v.SetDefault("server.recording_max_disk_bytes", 10737418240) // 10 GiB
+	v.SetDefault("server.recording_max_age_hours", 72)
+
+	_ = v.BindEnv("server.port", "PORT")
+	_ = v.BindEnv("server.fps", "FPS")
+	_ = v.BindEnv("server.fetch_fps", "FETCH_FPS")
+	_ = v.BindEnv("server.use_cache", "USE_CACHE")
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
 	}
 
+	return &Loader{v: v}, nil
+}
+
+// Load decodes the current config.yaml (plus any env overrides) into a
+// Config.
+func (l *Loader) Load() (*Config, error) {
+	cfg := &Config{}
+	if err := l.v.Unmarshal(cfg); err != nil {
+		return nil, fmt.Errorf("unmarshal config: %w", err)
+	}
 	return cfg, nil
 }
+
+// OnChange re-reads config.yaml whenever it's edited on disk and invokes
+// fn with the freshly decoded Config. Decode errors are logged and
+// otherwise ignored, leaving the previous config in effect.
+func (l *Loader) OnChange(fn func(*Config)) {
+	l.v.OnConfigChange(func(fsnotify.Event) {
+		cfg, err := l.Load()
+		if err != nil {
+			log.Printf("config reload error: %v", err)
+			return
+		}
+		fn(cfg)
+	})
+	l.v.WatchConfig()
+}
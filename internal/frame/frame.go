@@ -10,7 +10,6 @@ import (
 	"github.com/BrunoKrugel/snapshot2stream/internal/client"
 	"github.com/BrunoKrugel/snapshot2stream/internal/config"
 	"github.com/BrunoKrugel/snapshot2stream/internal/model"
-	"github.com/BrunoKrugel/snapshot2stream/internal/utils"
 )
 
 // CameraCache holds a ring buffer of frames for smoother playback
@@ -29,22 +28,205 @@ func NewCameraCache(size int) *CameraCache {
 	}
 }
 
-// FrameManager manages frame caching and fetching for all cameras
+// Registrar attaches a camera's HTTP handlers to mux whenever FrameManager
+// rebuilds its ServeMux, so callers can expose their own routes (MJPEG,
+// HLS, recordings, ...) without FrameManager knowing about them.
+type Registrar func(mux *http.ServeMux, cam config.CameraConfig)
+
+// FrameManager manages frame caching and fetching for all cameras, and
+// owns the http.ServeMux their handlers are registered on so config
+// changes can start/stop cameras and swap the mux in atomically.
 type FrameManager struct {
-	Caches map[string]*CameraCache
+	ctx    context.Context
 	Client *client.Client
+
+	mu      sync.Mutex
+	Caches  map[string]*CameraCache
+	cancels map[string]context.CancelFunc
+	configs map[string]config.CameraConfig
+
+	// server holds the last Server config seen via Reconcile, so
+	// startCameraLocked can decide whether a background fetcher is needed
+	// at all.
+	server config.Server
+
+	subMu sync.Mutex
+	subs  map[string][]chan *model.Frame
+
+	muxMu sync.RWMutex
+	mux   *http.ServeMux
+
+	registrar Registrar
+	onStart   func(config.CameraConfig)
+	onStop    func(name string)
 }
 
-func NewFrameManager(cfg *config.Config, client *client.Client) *FrameManager {
+// NewFrameManager builds a FrameManager whose per-camera fetchers are all
+// derived from ctx, so cancelling ctx stops every camera.
+func NewFrameManager(ctx context.Context, restyClient *client.Client) *FrameManager {
 	return &FrameManager{
-		Caches: make(map[string]*CameraCache),
-		Client: client,
+		ctx:     ctx,
+		Client:  restyClient,
+		Caches:  make(map[string]*CameraCache),
+		cancels: make(map[string]context.CancelFunc),
+		configs: make(map[string]config.CameraConfig),
+		subs:    make(map[string][]chan *model.Frame),
+		mux:     http.NewServeMux(),
+	}
+}
+
+// SetRegistrar sets the callback used to attach per-camera HTTP handlers
+// whenever the ServeMux is rebuilt.
+func (fm *FrameManager) SetRegistrar(r Registrar) {
+	fm.registrar = r
+}
+
+// OnCameraStart sets a callback invoked once whenever a camera's fetcher
+// (re)starts, e.g. so HLS/recording can be started alongside it.
+func (fm *FrameManager) OnCameraStart(fn func(config.CameraConfig)) {
+	fm.onStart = fn
+}
+
+// OnCameraStop sets a callback invoked once whenever a camera's fetcher
+// stops, e.g. so HLS/recording can be stopped alongside it.
+func (fm *FrameManager) OnCameraStop(fn func(name string)) {
+	fm.onStop = fn
+}
+
+// ServeHTTP implements http.Handler by delegating to whichever ServeMux
+// is currently active, so config changes take effect without restarting
+// the listener.
+func (fm *FrameManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fm.muxMu.RLock()
+	mux := fm.mux
+	fm.muxMu.RUnlock()
+
+	mux.ServeHTTP(w, r)
+}
+
+// Reconcile starts/stops per-camera fetchers so the running set matches
+// cameras, then rebuilds the ServeMux and swaps it in atomically. Safe to
+// call repeatedly, e.g. from config.Loader's OnChange.
+func (fm *FrameManager) Reconcile(server config.Server, cameras []config.CameraConfig) {
+	desired := make(map[string]config.CameraConfig, len(cameras))
+	for _, cam := range cameras {
+		if !cam.Enabled {
+			continue
+		}
+		if cam.FetchFPS < 1 {
+			cam.FetchFPS = server.FetchFPS
+		}
+		if cam.FetchFPS < 1 {
+			cam.FetchFPS = 1
+		}
+		desired[cam.Name] = cam
+	}
+
+	var stopped []string
+	var started []config.CameraConfig
+
+	fm.mu.Lock()
+	fm.server = server
+	for name := range fm.configs {
+		if _, ok := desired[name]; !ok {
+			fm.stopCameraLocked(name)
+			stopped = append(stopped, name)
+		}
+	}
+
+	for name, cam := range desired {
+		if existing, running := fm.configs[name]; running {
+			if existing == cam {
+				continue
+			}
+			fm.stopCameraLocked(name)
+			stopped = append(stopped, name)
+		}
+		fm.startCameraLocked(cam)
+		started = append(started, cam)
+	}
+	fm.mu.Unlock()
+
+	// onStop/onStart run HLS/recorder setup and teardown (ffmpeg fork+exec,
+	// bbolt I/O), so they must run outside fm.mu or they'd stall every
+	// GetNextFrame/GetLatestFrame/Subscribe call for the duration.
+	for _, name := range stopped {
+		if fm.onStop != nil {
+			fm.onStop(name)
+		}
+	}
+	for _, cam := range started {
+		if fm.onStart != nil {
+			fm.onStart(cam)
+		}
+	}
+
+	fm.rebuildMux(desired)
+}
+
+// startCameraLocked must be called with fm.mu held. cam.FetchFPS is
+// expected to already be defaulted by Reconcile. It does not invoke
+// fm.onStart; the caller does that once fm.mu is released.
+func (fm *FrameManager) startCameraLocked(cam config.CameraConfig) {
+	cacheSize := cam.CacheSize
+	if cacheSize < 1 {
+		cacheSize = 10
+	}
+	fm.Caches[cam.Name] = NewCameraCache(cacheSize)
+
+	ctx, cancel := context.WithCancel(fm.ctx)
+	fm.cancels[cam.Name] = cancel
+	fm.configs[cam.Name] = cam
+
+	// Only run the background fetcher when something actually reads from
+	// the cache/Subscribe fan-out: the cache-backed MJPEG path, HLS, or
+	// recording. Otherwise streamCameraDirect already fetches per request,
+	// and running both would double the load on the camera.
+	if fm.server.UseCache || fm.server.HLSEnabled || fm.server.RecordingEnabled {
+		go fm.StartFetcher(ctx, cam)
+	}
+}
+
+// stopCameraLocked must be called with fm.mu held. It does not invoke
+// fm.onStop; the caller does that once fm.mu is released.
+func (fm *FrameManager) stopCameraLocked(name string) {
+	if cancel, ok := fm.cancels[name]; ok {
+		cancel()
 	}
+	delete(fm.cancels, name)
+	delete(fm.configs, name)
+	delete(fm.Caches, name)
 }
 
-// StartFetcher runs in a goroutine to continuously fetch frames for a camera
-func (fm *FrameManager) StartFetcher(ctx context.Context, cameraName, cameraURL string, cfg *config.Config) {
-	frameInterval := time.Duration(1000/cfg.Server.FetchFPS) * time.Millisecond
+func (fm *FrameManager) rebuildMux(desired map[string]config.CameraConfig) {
+	mux := http.NewServeMux()
+	if fm.registrar != nil {
+		for _, cam := range desired {
+			fm.registrar(mux, cam)
+		}
+	}
+
+	fm.muxMu.Lock()
+	fm.mux = mux
+	fm.muxMu.Unlock()
+}
+
+// StartFetcher runs in a goroutine to continuously fetch frames for a
+// camera. It picks the Source implementation based on cam.InputMode:
+// snapshot-HTTP cameras are polled on a FetchFPS ticker, RTSP cameras are
+// drained by drainSource, and both write into the cache via
+// fetchFromSource.
+func (fm *FrameManager) StartFetcher(ctx context.Context, cam config.CameraConfig) {
+	if cam.InputMode == config.InputModeRTSP {
+		rtspFetcher := NewRTSPFetcher(cam.Name, cam.URL, cam.FetchFPS, cam.MaxThread)
+		rtspFetcher.Start(ctx)
+		defer rtspFetcher.Close()
+		fm.drainSource(ctx, cam.Name, rtspFetcher)
+		return
+	}
+
+	source := client.NewSnapshotSource(fm.Client, cam.URL, cam.AuthToken, cam.Cookie)
+	frameInterval := time.Duration(1000/cam.FetchFPS) * time.Millisecond
 	ticker := time.NewTicker(frameInterval)
 	defer ticker.Stop()
 
@@ -53,57 +235,97 @@ func (fm *FrameManager) StartFetcher(ctx context.Context, cameraName, cameraURL
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			fm.FetchFrame(cameraName, cameraURL)
+			fm.fetchFromSource(ctx, cam.Name, source)
 		}
 	}
 }
 
-// FetchFrame fetches a single frame and updates the cache
-func (fm *FrameManager) FetchFrame(cameraName, cameraURL string) {
-	resp, err := fm.Client.GetStream(cameraURL)
-	if err != nil {
-		log.Printf("[%s] request error: %v", cameraName, err)
-		return
-	}
-
-	if resp.RawResponse != nil && resp.RawResponse.Body != nil {
-		defer resp.RawResponse.Body.Close()
-	}
-
-	if resp.StatusCode() != http.StatusOK {
-		log.Printf("[%s] bad status: %s", cameraName, resp.Status())
-		return
+// drainSource continuously pulls frames from source into the cache. It's
+// used for sources like RTSPFetcher that pace themselves internally, so
+// the outer loop just drains whatever is ready.
+func (fm *FrameManager) drainSource(ctx context.Context, cameraName string, source client.Source) {
+	for ctx.Err() == nil {
+		fm.fetchFromSource(ctx, cameraName, source)
 	}
+}
 
-	body := resp.Body()
-	if len(body) == 0 {
+// fetchFromSource fetches a single frame from source and writes it into
+// the camera's ring buffer.
+func (fm *FrameManager) fetchFromSource(ctx context.Context, cameraName string, source client.Source) {
+	newFrame, err := source.Fetch(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("[%s] fetch error: %v", cameraName, err)
+		}
 		return
 	}
 
-	// Validate JPEG frame
-	if !utils.IsValidJPEG(body) {
-		log.Printf("[%s] invalid JPEG frame, skipping", cameraName)
+	fm.mu.Lock()
+	cache := fm.Caches[cameraName]
+	fm.mu.Unlock()
+	if cache == nil {
 		return
 	}
 
-	// Update cache with new frame
-	cache := fm.Caches[cameraName]
 	cache.mu.Lock()
-	newFrame := &model.Frame{
-		Data:      make([]byte, len(body)),
-		Timestamp: time.Now(),
-	}
-	copy(newFrame.Data, body)
-
-	// Add to ring buffer
 	cache.frames[cache.writeIndex] = newFrame
 	cache.writeIndex = (cache.writeIndex + 1) % cache.size
 	cache.mu.Unlock()
+
+	fm.publish(cameraName, newFrame)
+}
+
+// Subscribe returns a channel that receives every frame fetched for
+// cameraName, alongside it being written into the ring buffer, so
+// consumers like the recorder share the same fetch instead of polling
+// the camera themselves. The channel is bounded; a slow subscriber has
+// frames dropped rather than blocking the fetcher. Call Unsubscribe when
+// done with it.
+func (fm *FrameManager) Subscribe(cameraName string) <-chan *model.Frame {
+	ch := make(chan *model.Frame, 8)
+
+	fm.subMu.Lock()
+	fm.subs[cameraName] = append(fm.subs[cameraName], ch)
+	fm.subMu.Unlock()
+
+	return ch
+}
+
+// Unsubscribe stops delivering frames to a channel returned by Subscribe
+// and closes it.
+func (fm *FrameManager) Unsubscribe(cameraName string, ch <-chan *model.Frame) {
+	fm.subMu.Lock()
+	defer fm.subMu.Unlock()
+
+	subs := fm.subs[cameraName]
+	for i, c := range subs {
+		if c == ch {
+			fm.subs[cameraName] = append(subs[:i], subs[i+1:]...)
+			close(c)
+			return
+		}
+	}
+}
+
+// publish fans a frame out to every subscriber of cameraName, dropping it
+// for subscribers whose queue is full.
+func (fm *FrameManager) publish(cameraName string, newFrame *model.Frame) {
+	fm.subMu.Lock()
+	defer fm.subMu.Unlock()
+
+	for _, ch := range fm.subs[cameraName] {
+		select {
+		case ch <- newFrame:
+		default:
+		}
+	}
 }
 
 // GetLatestFrame returns the latest cached frame for a camera
 func (fm *FrameManager) GetLatestFrame(cameraName string) *model.Frame {
+	fm.mu.Lock()
 	cache, exists := fm.Caches[cameraName]
+	fm.mu.Unlock()
 	if !exists {
 		return nil
 	}
@@ -118,7 +340,9 @@ func (fm *FrameManager) GetLatestFrame(cameraName string) *model.Frame {
 
 // GetNextFrame returns the next frame for streaming
 func (fm *FrameManager) GetNextFrame(cameraName string) *model.Frame {
+	fm.mu.Lock()
 	cache, exists := fm.Caches[cameraName]
+	fm.mu.Unlock()
 	if !exists {
 		return nil
 	}
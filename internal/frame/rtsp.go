@@ -0,0 +1,173 @@
+package frame
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gocv.io/x/gocv"
+
+	"github.com/BrunoKrugel/snapshot2stream/internal/model"
+	"github.com/BrunoKrugel/snapshot2stream/internal/utils"
+)
+
+// RTSPFetcher implements client.Source by keeping a single persistent RTSP
+// session open and JPEG-encoding decoded frames at fetchFPS, so cameras
+// without a frequent snapshot endpoint can still feed a CameraCache.
+type RTSPFetcher struct {
+	cameraName string
+	url        string
+	fetchFPS   int
+	maxThread  int
+
+	frames chan *model.Frame
+	cancel context.CancelFunc
+}
+
+// NewRTSPFetcher builds a fetcher for a single RTSP camera. maxThread
+// bounds the number of frames being decoded/encoded concurrently so one
+// slow camera can't starve the others.
+func NewRTSPFetcher(cameraName, url string, fetchFPS, maxThread int) *RTSPFetcher {
+	if maxThread < 1 {
+		maxThread = 1
+	}
+
+	return &RTSPFetcher{
+		cameraName: cameraName,
+		url:        url,
+		fetchFPS:   fetchFPS,
+		maxThread:  maxThread,
+		frames:     make(chan *model.Frame, maxThread),
+	}
+}
+
+// Start opens the RTSP session in the background and keeps it alive,
+// reconnecting with exponential backoff whenever it drops. Fetch consumes
+// the frames it decodes.
+func (r *RTSPFetcher) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	r.cancel = cancel
+	go r.run(ctx)
+}
+
+func (r *RTSPFetcher) run(ctx context.Context) {
+	backoff := time.Second
+	const maxBackoff = 30 * time.Second
+
+	for ctx.Err() == nil {
+		if err := r.consume(ctx); err != nil {
+			log.Printf("[%s] rtsp session ended: %v (retrying in %s)", r.cameraName, err, backoff)
+		} else {
+			backoff = time.Second
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// consume opens a single RTSP session and decodes frames off it until the
+// session errors out or ctx is cancelled.
+func (r *RTSPFetcher) consume(ctx context.Context) error {
+	capture, err := gocv.OpenVideoCapture(r.url)
+	if err != nil {
+		return fmt.Errorf("open rtsp stream: %w", err)
+	}
+	defer capture.Close()
+
+	interval := time.Duration(1000/r.fetchFPS) * time.Millisecond
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	sem := make(chan struct{}, r.maxThread)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		mat := gocv.NewMat()
+		if !capture.Read(&mat) {
+			mat.Close()
+			return fmt.Errorf("rtsp read failed")
+		}
+		if mat.Empty() {
+			mat.Close()
+			continue
+		}
+
+		sem <- struct{}{}
+		go func(decoded gocv.Mat) {
+			defer func() { <-sem }()
+			defer decoded.Close()
+			r.emit(decoded)
+		}(mat)
+	}
+}
+
+// emit JPEG-encodes a decoded frame and queues it for Fetch, dropping the
+// oldest queued frame rather than blocking decode of newer ones.
+func (r *RTSPFetcher) emit(mat gocv.Mat) {
+	buf, err := gocv.IMEncode(gocv.JPEGFileExt, mat)
+	if err != nil {
+		log.Printf("[%s] jpeg encode error: %v", r.cameraName, err)
+		return
+	}
+	defer buf.Close()
+
+	data := append([]byte(nil), buf.GetBytes()...)
+	if !utils.IsValidJPEG(data) {
+		log.Printf("[%s] invalid JPEG frame, skipping", r.cameraName)
+		return
+	}
+
+	newFrame := &model.Frame{Data: data, Timestamp: time.Now()}
+
+	select {
+	case r.frames <- newFrame:
+		return
+	default:
+	}
+
+	// r.frames was full: drop the oldest queued frame and retry once. A
+	// concurrent worker can refill the slot we just freed before we get to
+	// it, so the retry is non-blocking too; losing this frame in that race
+	// is fine, the point is never blocking a decode worker.
+	select {
+	case <-r.frames:
+	default:
+	}
+	select {
+	case r.frames <- newFrame:
+	default:
+	}
+}
+
+// Fetch implements client.Source, returning the next decoded frame.
+func (r *RTSPFetcher) Fetch(ctx context.Context) (*model.Frame, error) {
+	select {
+	case frame := <-r.frames:
+		return frame, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close stops the background RTSP session.
+func (r *RTSPFetcher) Close() error {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+// Package hls drives a per-camera ffmpeg process that turns the JPEGs in a
+// frame.CameraCache into a rolling window of MPEG-TS segments, and serves
+// them as a live HLS playlist.
+package hls
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/BrunoKrugel/snapshot2stream/internal/frame"
+	"github.com/BrunoKrugel/snapshot2stream/internal/model"
+)
+
+// Manager owns one ffmpeg process per camera: it feeds cached JPEGs into
+// ffmpeg's stdin and rotates the MPEG-TS segments ffmpeg writes out into a
+// bounded ring, serving them as live HLS via GetPlaylist/GetSegment.
+type Manager struct {
+	fm              *frame.FrameManager
+	outputFPS       int
+	segmentDuration time.Duration
+	segmentCount    int
+
+	mu      sync.Mutex
+	streams map[string]*stream
+}
+
+func NewManager(fm *frame.FrameManager, outputFPS int, segmentDuration time.Duration, segmentCount int) *Manager {
+	return &Manager{
+		fm:              fm,
+		outputFPS:       outputFPS,
+		segmentDuration: segmentDuration,
+		segmentCount:    segmentCount,
+		streams:         make(map[string]*stream),
+	}
+}
+
+// Start launches the per-camera ffmpeg process and the feeder goroutine
+// that writes cached frames to its stdin. It's a no-op if a stream for
+// cameraName is already running. Everything it starts is torn down when
+// ctx is cancelled.
+func (m *Manager) Start(ctx context.Context, cameraName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.streams[cameraName]; exists {
+		return nil
+	}
+
+	dir, err := os.MkdirTemp("", "hls-"+cameraName+"-")
+	if err != nil {
+		return fmt.Errorf("create segment dir: %w", err)
+	}
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	s := &stream{cameraName: cameraName, dir: dir, cancel: cancel}
+
+	cmd := exec.CommandContext(streamCtx, "ffmpeg",
+		"-loglevel", "error",
+		"-f", "image2pipe",
+		"-framerate", strconv.Itoa(m.outputFPS),
+		"-c:v", "mjpeg",
+		"-i", "pipe:0",
+		"-c:v", "libx264",
+		"-f", "segment",
+		"-segment_time", fmt.Sprintf("%.3f", m.segmentDuration.Seconds()),
+		"-segment_format", "mpegts",
+		"-reset_timestamps", "1",
+		filepath.Join(dir, "segment-%d.ts"),
+	)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		os.RemoveAll(dir)
+		return fmt.Errorf("open ffmpeg stdin: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		os.RemoveAll(dir)
+		return fmt.Errorf("start ffmpeg: %w", err)
+	}
+
+	m.streams[cameraName] = s
+
+	frames := m.fm.Subscribe(cameraName)
+	go m.feed(streamCtx, cameraName, frames, stdin)
+	go m.watchSegments(streamCtx, s)
+	go func() {
+		if err := cmd.Wait(); err != nil && streamCtx.Err() == nil {
+			log.Printf("[%s] hls ffmpeg exited: %v", cameraName, err)
+		}
+	}()
+
+	return nil
+}
+
+// Stop tears down cameraName's ffmpeg process, feeder, and segment
+// watcher, and removes its segment directory. It's a no-op if no stream
+// for cameraName is running.
+func (m *Manager) Stop(cameraName string) {
+	m.mu.Lock()
+	s, exists := m.streams[cameraName]
+	if exists {
+		delete(m.streams, cameraName)
+	}
+	m.mu.Unlock()
+
+	if exists {
+		s.cancel()
+	}
+}
+
+// feed reads frames from the camera's Subscribe fan-out and writes their
+// JPEG bytes to ffmpeg's stdin, the image2pipe input it expects. It uses
+// Subscribe rather than GetNextFrame so it doesn't compete with the
+// cache's shared read index, e.g. against MJPEG clients.
+func (m *Manager) feed(ctx context.Context, cameraName string, frames <-chan *model.Frame, w io.WriteCloser) {
+	defer w.Close()
+	defer m.fm.Unsubscribe(cameraName, frames)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case f, ok := <-frames:
+			if !ok {
+				return
+			}
+			if _, err := w.Write(f.Data); err != nil {
+				log.Printf("[%s] hls feed write error: %v", cameraName, err)
+				return
+			}
+		}
+	}
+}
+
+// GetPlaylist renders the current live playlist for a camera.
+func (m *Manager) GetPlaylist(cameraName string) (string, error) {
+	s, err := m.stream(cameraName)
+	if err != nil {
+		return "", err
+	}
+	return s.playlist(), nil
+}
+
+// GetSegment returns the bytes of segment n for a camera.
+func (m *Manager) GetSegment(cameraName string, n int) ([]byte, error) {
+	s, err := m.stream(cameraName)
+	if err != nil {
+		return nil, err
+	}
+	return s.segmentData(n)
+}
+
+func (m *Manager) stream(cameraName string) (*stream, error) {
+	m.mu.Lock()
+	s, exists := m.streams[cameraName]
+	m.mu.Unlock()
+
+	if !exists {
+		return nil, fmt.Errorf("no hls stream for camera %q", cameraName)
+	}
+	return s, nil
+}
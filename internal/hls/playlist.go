@@ -0,0 +1,41 @@
+package hls
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// playlist renders an LL-HLS-friendly live playlist: independent segments
+// so a client can start decoding from any one of them.
+func (s *stream) playlist() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:6\n")
+	b.WriteString(fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", targetDuration(s.segments)))
+	b.WriteString(fmt.Sprintf("#EXT-X-MEDIA-SEQUENCE:%d\n", s.mediaSeq))
+	b.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+
+	for _, seg := range s.segments {
+		b.WriteString(fmt.Sprintf("#EXTINF:%.3f,\n", seg.duration))
+		b.WriteString(fmt.Sprintf("segment-%d.ts\n", seg.seq))
+	}
+
+	return b.String()
+}
+
+// targetDuration is the ceiling of the longest segment duration in the
+// playlist (at least 1), per the HLS spec's EXT-X-TARGETDURATION
+// requirement.
+func targetDuration(segments []segmentInfo) int {
+	max := 1.0
+	for _, seg := range segments {
+		if seg.duration > max {
+			max = seg.duration
+		}
+	}
+	return int(math.Ceil(max))
+}
@@ -0,0 +1,124 @@
+package hls
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stream tracks one camera's ffmpeg output directory and the segments it
+// has produced so far.
+type stream struct {
+	cameraName string
+	dir        string
+	cancel     context.CancelFunc
+
+	mu       sync.RWMutex
+	segments []segmentInfo
+	mediaSeq int
+}
+
+type segmentInfo struct {
+	seq      int
+	duration float64
+	path     string
+}
+
+// watchSegments polls the ffmpeg output directory for newly completed
+// segments and prunes the oldest ones once more than segmentCount exist.
+func (m *Manager) watchSegments(ctx context.Context, s *stream) {
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	seen := make(map[int]bool)
+
+	for {
+		select {
+		case <-ctx.Done():
+			os.RemoveAll(s.dir)
+			return
+		case <-ticker.C:
+		}
+
+		entries, err := os.ReadDir(s.dir)
+		if err != nil {
+			continue
+		}
+
+		var seqs []int
+		for _, e := range entries {
+			seq, ok := segmentSeq(e.Name())
+			if ok {
+				seqs = append(seqs, seq)
+			}
+		}
+		sort.Ints(seqs)
+
+		// The newest segment may still be open for writing; only the
+		// ones before it are guaranteed complete.
+		if len(seqs) < 2 {
+			continue
+		}
+		seqs = seqs[:len(seqs)-1]
+
+		for _, seq := range seqs {
+			if seen[seq] {
+				continue
+			}
+			seen[seq] = true
+			s.addSegment(seq, filepath.Join(s.dir, fmt.Sprintf("segment-%d.ts", seq)), m.segmentDuration.Seconds(), m.segmentCount)
+		}
+	}
+}
+
+func segmentSeq(name string) (int, bool) {
+	if !strings.HasPrefix(name, "segment-") || !strings.HasSuffix(name, ".ts") {
+		return 0, false
+	}
+	seq, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "segment-"), ".ts"))
+	if err != nil {
+		return 0, false
+	}
+	return seq, true
+}
+
+// addSegment records a newly completed segment and drops the oldest ones
+// once more than keep are held, advancing the playlist's media sequence
+// so clients know segments were removed.
+func (s *stream) addSegment(seq int, path string, duration float64, keep int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.segments = append(s.segments, segmentInfo{seq: seq, duration: duration, path: path})
+
+	for len(s.segments) > keep {
+		dropped := s.segments[0]
+		s.segments = s.segments[1:]
+		os.Remove(dropped.path)
+		s.mediaSeq++
+	}
+}
+
+// segmentData returns the bytes of segment seq, reading it back from disk.
+func (s *stream) segmentData(seq int) ([]byte, error) {
+	s.mu.RLock()
+	var path string
+	for _, seg := range s.segments {
+		if seg.seq == seq {
+			path = seg.path
+			break
+		}
+	}
+	s.mu.RUnlock()
+
+	if path == "" {
+		return nil, fmt.Errorf("segment %d not available", seq)
+	}
+	return os.ReadFile(path)
+}
@@ -0,0 +1,142 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Entry describes one recorded segment. For the per-frame JPEG layout
+// written by Manager, Start and End are equal; the schema leaves room for
+// a future multi-frame segment (e.g. MJPEG-in-MP4) to span a range.
+type Entry struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	File  string    `json:"file"`
+	Size  int64     `json:"size"`
+}
+
+// index is a bbolt-backed store of Entry records, one bucket per camera,
+// keyed by start timestamp so range queries and oldest-entry lookups are
+// a single cursor seek.
+type index struct {
+	db *bolt.DB
+}
+
+func openIndex(path string) (*index, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open index: %w", err)
+	}
+	return &index{db: db}, nil
+}
+
+func (idx *index) close() error {
+	return idx.db.Close()
+}
+
+func (idx *index) put(cameraName string, e Entry) error {
+	return idx.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(cameraName))
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(timeKey(e.Start), data)
+	})
+}
+
+// query returns every entry for cameraName whose Start lies in [from, to].
+// An entry that starts before from is not returned, even if its End falls
+// inside the range.
+func (idx *index) query(cameraName string, from, to time.Time) ([]Entry, error) {
+	var entries []Entry
+
+	err := idx.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(cameraName))
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+		for k, v := c.Seek(timeKey(from)); k != nil && !keyTime(k).After(to); k, v = c.Next() {
+			var e Entry
+			if err := json.Unmarshal(v, &e); err != nil {
+				continue
+			}
+			entries = append(entries, e)
+		}
+		return nil
+	})
+
+	return entries, err
+}
+
+// oldest returns the earliest entry for cameraName, used by retention
+// pruning to decide what to drop next.
+func (idx *index) oldest(cameraName string) (Entry, bool, error) {
+	var e Entry
+	found := false
+
+	err := idx.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(cameraName))
+		if bucket == nil {
+			return nil
+		}
+
+		k, v := bucket.Cursor().First()
+		if k == nil {
+			return nil
+		}
+
+		found = true
+		return json.Unmarshal(v, &e)
+	})
+
+	return e, found, err
+}
+
+// deleteOldest atomically removes the earliest entry for cameraName.
+func (idx *index) deleteOldest(cameraName string) (Entry, bool, error) {
+	var e Entry
+	found := false
+
+	err := idx.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(cameraName))
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+		k, v := c.First()
+		if k == nil {
+			return nil
+		}
+
+		if err := json.Unmarshal(v, &e); err != nil {
+			return err
+		}
+		found = true
+		return c.Delete()
+	})
+
+	return e, found, err
+}
+
+func timeKey(t time.Time) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(t.UnixNano()))
+	return buf
+}
+
+func keyTime(k []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(k)))
+}
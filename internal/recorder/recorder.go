@@ -0,0 +1,314 @@
+// Package recorder continuously writes each camera's frames to disk and
+// enforces a retention policy, keeping a queryable index of what's
+// available so it can be played back by time range later.
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/BrunoKrugel/snapshot2stream/internal/frame"
+	"github.com/BrunoKrugel/snapshot2stream/internal/model"
+)
+
+// Manager records frames from a frame.FrameManager subscription into
+// rolling per-frame JPEGs under baseDir, with an index of
+// (camera, start_ts, end_ts, file) records keyed by camera and start_ts
+// so range queries and retention pruning stay O(log n). maxDiskBytes is enforced per camera, not
+// across the whole recorder, so N cameras use up to N*maxDiskBytes.
+type Manager struct {
+	fm      *frame.FrameManager
+	baseDir string
+
+	maxDiskBytes int64
+	maxAge       time.Duration
+
+	idx *index
+
+	usageMu sync.Mutex
+	usage   map[string]int64 // cameraName -> bytes on disk, kept current incrementally
+
+	streamsMu sync.Mutex
+	streams   map[string]context.CancelFunc
+}
+
+func NewManager(fm *frame.FrameManager, baseDir string, maxDiskBytes int64, maxAgeHours int) (*Manager, error) {
+	if err := os.MkdirAll(baseDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create recordings dir: %w", err)
+	}
+
+	idx, err := openIndex(filepath.Join(baseDir, "index.db"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Manager{
+		fm:           fm,
+		baseDir:      baseDir,
+		maxDiskBytes: maxDiskBytes,
+		maxAge:       time.Duration(maxAgeHours) * time.Hour,
+		idx:          idx,
+		usage:        make(map[string]int64),
+		streams:      make(map[string]context.CancelFunc),
+	}, nil
+}
+
+// Close releases the index file. Recording goroutines stop on their own
+// once ctx passed to Start is cancelled.
+func (m *Manager) Close() error {
+	return m.idx.close()
+}
+
+// Start subscribes to cameraName's frames and records them to disk until
+// ctx is cancelled or Stop is called, alongside a goroutine enforcing
+// retention. It's a no-op if cameraName is already being recorded.
+func (m *Manager) Start(ctx context.Context, cameraName string) error {
+	m.streamsMu.Lock()
+	defer m.streamsMu.Unlock()
+
+	if _, exists := m.streams[cameraName]; exists {
+		return nil
+	}
+
+	cameraDir := filepath.Join(m.baseDir, cameraName)
+	if err := os.MkdirAll(cameraDir, 0o755); err != nil {
+		return fmt.Errorf("create camera dir: %w", err)
+	}
+
+	if err := m.seedUsage(cameraName); err != nil {
+		return fmt.Errorf("seed disk usage: %w", err)
+	}
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	m.streams[cameraName] = cancel
+
+	frames := m.fm.Subscribe(cameraName)
+	go m.record(streamCtx, cameraName, cameraDir, frames)
+	go m.enforceRetention(streamCtx, cameraName)
+
+	return nil
+}
+
+// Stop ends recording and retention for cameraName. Recorded files and
+// their index entries are left in place; only the live subscription and
+// goroutines are torn down. It's a no-op if cameraName isn't running.
+func (m *Manager) Stop(cameraName string) {
+	m.streamsMu.Lock()
+	cancel, exists := m.streams[cameraName]
+	if exists {
+		delete(m.streams, cameraName)
+	}
+	m.streamsMu.Unlock()
+
+	if exists {
+		cancel()
+	}
+}
+
+// seedUsage sums the size of cameraName's existing recordings once at
+// startup, so usage stays accurate across restarts without rescanning
+// the index on every retention check.
+func (m *Manager) seedUsage(cameraName string) error {
+	entries, err := m.idx.query(cameraName, time.Unix(0, 0), time.Now())
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+
+	m.usageMu.Lock()
+	m.usage[cameraName] = total
+	m.usageMu.Unlock()
+	return nil
+}
+
+func (m *Manager) record(ctx context.Context, cameraName, cameraDir string, frames <-chan *model.Frame) {
+	defer m.fm.Unsubscribe(cameraName, frames)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case f, ok := <-frames:
+			if !ok {
+				return
+			}
+			m.writeFrame(cameraName, cameraDir, f)
+		}
+	}
+}
+
+func (m *Manager) writeFrame(cameraName, cameraDir string, f *model.Frame) {
+	path := filepath.Join(cameraDir, fmt.Sprintf("%d.jpg", f.Timestamp.UnixNano()))
+
+	if err := os.WriteFile(path, f.Data, 0o644); err != nil {
+		log.Printf("[%s] recorder: write frame: %v", cameraName, err)
+		return
+	}
+
+	entry := Entry{Start: f.Timestamp, End: f.Timestamp, File: path, Size: int64(len(f.Data))}
+	if err := m.idx.put(cameraName, entry); err != nil {
+		log.Printf("[%s] recorder: index frame: %v", cameraName, err)
+		return
+	}
+
+	m.usageMu.Lock()
+	m.usage[cameraName] += entry.Size
+	m.usageMu.Unlock()
+}
+
+// enforceRetention periodically drops the oldest recordings for
+// cameraName once they exceed maxDiskBytes or maxAge.
+func (m *Manager) enforceRetention(ctx context.Context, cameraName string) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.pruneOnce(cameraName)
+		}
+	}
+}
+
+func (m *Manager) pruneOnce(cameraName string) {
+	for {
+		entry, found, err := m.idx.oldest(cameraName)
+		if err != nil || !found {
+			return
+		}
+
+		tooOld := m.maxAge > 0 && time.Since(entry.Start) > m.maxAge
+		tooBig := m.maxDiskBytes > 0 && m.diskUsage(cameraName) > m.maxDiskBytes
+		if !tooOld && !tooBig {
+			return
+		}
+
+		m.dropOldest(cameraName)
+	}
+}
+
+func (m *Manager) dropOldest(cameraName string) {
+	entry, found, err := m.idx.deleteOldest(cameraName)
+	if err != nil || !found {
+		return
+	}
+	if err := os.Remove(entry.File); err != nil && !os.IsNotExist(err) {
+		log.Printf("[%s] recorder: remove %s: %v", cameraName, entry.File, err)
+	}
+
+	m.usageMu.Lock()
+	m.usage[cameraName] -= entry.Size
+	m.usageMu.Unlock()
+}
+
+// diskUsage returns cameraName's running byte total, kept current by
+// writeFrame/dropOldest instead of rescanning the index on every check
+// (pruneOnce calls this once per dropped entry, so a rescan here would
+// make bulk pruning O(n^2) over the index).
+func (m *Manager) diskUsage(cameraName string) int64 {
+	m.usageMu.Lock()
+	defer m.usageMu.Unlock()
+	return m.usage[cameraName]
+}
+
+// Index returns the recorded segments for cameraName whose Start lies in
+// [from, to].
+func (m *Manager) Index(cameraName string, from, to time.Time) ([]Entry, error) {
+	return m.idx.query(cameraName, from, to)
+}
+
+// SnapshotAt returns the JPEG bytes of the recording closest to at,
+// searching only one minute either side of at; it returns an error when
+// no recording falls in that window.
+func (m *Manager) SnapshotAt(cameraName string, at time.Time) ([]byte, error) {
+	entries, err := m.idx.query(cameraName, at.Add(-time.Minute), at.Add(time.Minute))
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no recording near %s for camera %q", at.Format(time.RFC3339), cameraName)
+	}
+
+	closest := entries[0]
+	for _, e := range entries[1:] {
+		if abs(e.Start.Sub(at)) < abs(closest.Start.Sub(at)) {
+			closest = e
+		}
+	}
+
+	return os.ReadFile(closest.File)
+}
+
+// ViewMP4 renders the recordings for cameraName in [from, to] into a
+// single MP4 via ffmpeg and streams it to w.
+func (m *Manager) ViewMP4(ctx context.Context, cameraName string, from, to time.Time, outputFPS int, w io.Writer) error {
+	entries, err := m.idx.query(cameraName, from, to)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no recordings in range for camera %q", cameraName)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Start.Before(entries[j].Start) })
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-loglevel", "error",
+		"-f", "image2pipe",
+		"-framerate", strconv.Itoa(outputFPS),
+		"-c:v", "mjpeg",
+		"-i", "pipe:0",
+		"-c:v", "libx264",
+		"-movflags", "frag_keyframe+empty_moov",
+		"-f", "mp4",
+		"pipe:1",
+	)
+	cmd.Stdout = w
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("open ffmpeg stdin: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start ffmpeg: %w", err)
+	}
+
+	go func() {
+		defer stdin.Close()
+		for _, e := range entries {
+			data, err := os.ReadFile(e.File)
+			if err != nil {
+				log.Printf("[%s] recorder: read %s: %v", cameraName, e.File, err)
+				continue
+			}
+			if _, err := stdin.Write(data); err != nil {
+				return
+			}
+		}
+	}()
+
+	return cmd.Wait()
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
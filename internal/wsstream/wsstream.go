@@ -0,0 +1,142 @@
+// Package wsstream pushes cached camera frames to browsers over a
+// WebSocket connection instead of multipart MJPEG. Each frame is sent as
+// a JSON text message with metadata followed by a binary message with the
+// raw JPEG bytes, which survives proxies that mangle x-mixed-replace and
+// leaves room for a future signaling channel (e.g. WebRTC).
+package wsstream
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BrunoKrugel/snapshot2stream/internal/frame"
+	"github.com/gorilla/websocket"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Camera streams are consumed by this app's own frontend from any
+	// origin it's hosted on, same as the MJPEG endpoint has no CORS check.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// frameMeta is the JSON text message sent immediately before each binary
+// frame.
+type frameMeta struct {
+	Timestamp time.Time `json:"timestamp"`
+	Seq       uint64    `json:"seq"`
+}
+
+// clientState is the per-connection throttle state, mutated by control
+// messages read from the client and read by the frame-sending loop.
+type clientState struct {
+	mu     sync.Mutex
+	paused bool
+	fps    int // 0 means "use every frame FrameManager delivers"
+}
+
+func (s *clientState) snapshot() (paused bool, fps int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused, s.fps
+}
+
+// Handle upgrades r to a WebSocket and streams frames for cameraName from
+// fm until the client disconnects. Each client subscribes independently
+// via fm.Subscribe, so a slow client has frames dropped rather than
+// blocking the fetcher or other clients.
+func Handle(fm *frame.FrameManager, cameraName string, w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("[%s] ws upgrade error: %v", cameraName, err)
+		return
+	}
+	defer conn.Close()
+
+	frames := fm.Subscribe(cameraName)
+	defer fm.Unsubscribe(cameraName, frames)
+
+	state := &clientState{}
+	done := make(chan struct{})
+	go readControl(conn, state, done)
+
+	var seq uint64
+	var lastSent time.Time
+
+	for {
+		select {
+		case <-done:
+			return
+		case f, ok := <-frames:
+			if !ok {
+				return
+			}
+
+			paused, fps := state.snapshot()
+			if paused {
+				continue
+			}
+			if fps > 0 && !lastSent.IsZero() && time.Since(lastSent) < time.Second/time.Duration(fps) {
+				continue
+			}
+
+			seq++
+			meta := frameMeta{Timestamp: f.Timestamp, Seq: seq}
+			metaJSON, err := json.Marshal(meta)
+			if err != nil {
+				continue
+			}
+
+			if err := conn.WriteMessage(websocket.TextMessage, metaJSON); err != nil {
+				return
+			}
+			if err := conn.WriteMessage(websocket.BinaryMessage, f.Data); err != nil {
+				return
+			}
+			lastSent = time.Now()
+		}
+	}
+}
+
+// readControl reads client->server text control messages (pause, resume,
+// set_fps N) and applies them to state until the connection closes, then
+// closes done so Handle's send loop stops too.
+func readControl(conn *websocket.Conn, state *clientState, done chan struct{}) {
+	defer close(done)
+
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if msgType != websocket.TextMessage {
+			continue
+		}
+
+		msg := strings.TrimSpace(string(data))
+		switch {
+		case msg == "pause":
+			state.mu.Lock()
+			state.paused = true
+			state.mu.Unlock()
+		case msg == "resume":
+			state.mu.Lock()
+			state.paused = false
+			state.mu.Unlock()
+		case strings.HasPrefix(msg, "set_fps "):
+			n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(msg, "set_fps ")))
+			if err != nil || n < 0 {
+				continue
+			}
+			state.mu.Lock()
+			state.fps = n
+			state.mu.Unlock()
+		}
+	}
+}
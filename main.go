@@ -2,69 +2,126 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/BrunoKrugel/snapshot2stream/internal/client"
 	"github.com/BrunoKrugel/snapshot2stream/internal/config"
 	"github.com/BrunoKrugel/snapshot2stream/internal/frame"
+	"github.com/BrunoKrugel/snapshot2stream/internal/hls"
+	"github.com/BrunoKrugel/snapshot2stream/internal/recorder"
+	"github.com/BrunoKrugel/snapshot2stream/internal/wsstream"
 	_ "github.com/joho/godotenv/autoload"
 )
 
 func main() {
-	cfg, err := config.NewConfig()
+	loader, err := config.NewLoader("config.yaml")
 	if err != nil {
 		panic(err)
 	}
 
-	client := client.NewRestyClient(cfg)
+	cfg, err := loader.Load()
+	if err != nil {
+		panic(err)
+	}
+
+	var cfgRef atomic.Pointer[config.Config]
+	cfgRef.Store(cfg)
+
+	restyClient := client.NewRestyClient()
 
 	// Create frame manager
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	frameManager := frame.NewFrameManager(cfg, client)
+	frameManager := frame.NewFrameManager(ctx, restyClient)
 
-	cameras := map[string]string{
-		"rua":        cfg.Cameras.Camera1,
-		"piscina":    cfg.Cameras.Camera2,
-		"encomendas": cfg.Cameras.Camera3,
-		"externo":    cfg.Cameras.Camera4,
-		"hall":       cfg.Cameras.Camera5,
-		"elevador":   cfg.Cameras.Camera6,
+	var hlsManager *hls.Manager
+	if cfg.Server.HLSEnabled {
+		hlsManager = hls.NewManager(frameManager, cfg.Server.FPS, time.Duration(cfg.Server.HLSSegmentDuration)*time.Second, cfg.Server.HLSSegmentCount)
 	}
 
-	// Initialize caches and start fetchers (only if cache is enabled)
-	for name, url := range cameras {
-		frameManager.Caches[name] = frame.NewCameraCache(10) // Ring buffer of 10 frames
-		if cfg.Server.UseCache {
-			// Start background fetcher for each camera
-			go frameManager.StartFetcher(ctx, name, url, cfg)
+	var recorderManager *recorder.Manager
+	if cfg.Server.RecordingEnabled {
+		recorderManager, err = recorder.NewManager(frameManager, cfg.Server.RecordingDir, cfg.Server.RecordingMaxDiskBytes, cfg.Server.RecordingMaxAgeHours)
+		if err != nil {
+			panic(err)
 		}
+		defer recorderManager.Close()
 	}
 
-	// Register handlers
-	for name, url := range cameras {
-		cameraName := name
-		cameraURL := url
-		http.HandleFunc("/"+cameraName, func(w http.ResponseWriter, r *http.Request) {
-			if cfg.Server.UseCache {
-				streamCameraFromCache(w, r, frameManager, cameraName, cfg)
+	frameManager.OnCameraStart(func(cam config.CameraConfig) {
+		if hlsManager != nil {
+			if err := hlsManager.Start(ctx, cam.Name); err != nil {
+				log.Printf("[%s] hls start error: %v", cam.Name, err)
+			}
+		}
+		if recorderManager != nil {
+			if err := recorderManager.Start(ctx, cam.Name); err != nil {
+				log.Printf("[%s] recorder start error: %v", cam.Name, err)
+			}
+		}
+		log.Printf("Camera started: %s (%s)", cam.Name, cam.URL)
+	})
+
+	frameManager.OnCameraStop(func(name string) {
+		if hlsManager != nil {
+			hlsManager.Stop(name)
+		}
+		if recorderManager != nil {
+			recorderManager.Stop(name)
+		}
+		log.Printf("Camera stopped: %s", name)
+	})
+
+	// The registrar runs once per camera every time the manager rebuilds
+	// its ServeMux, so it must only attach handlers, never start anything.
+	frameManager.SetRegistrar(func(mux *http.ServeMux, cam config.CameraConfig) {
+		cameraName := cam.Name
+
+		mux.HandleFunc("/"+cameraName, func(w http.ResponseWriter, r *http.Request) {
+			liveCfg := cfgRef.Load()
+			if liveCfg.Server.UseCache {
+				streamCameraFromCache(w, r, frameManager, cameraName, liveCfg)
 			} else {
-				streamCameraDirect(w, r, frameManager, cameraName, cameraURL, cfg)
+				streamCameraDirect(w, r, frameManager, cam, liveCfg)
 			}
 		})
-		log.Printf("Camera endpoint ready: http://localhost:%s/%s", cfg.Server.Port, cameraName)
-	}
 
-	cacheStatus := "enabled"
-	if !cfg.Server.UseCache {
-		cacheStatus = "disabled"
+		if hlsManager != nil {
+			registerHLSHandlers(mux, hlsManager, cameraName)
+		}
+		if recorderManager != nil {
+			registerRecorderHandlers(mux, recorderManager, cameraName, cfg.Server.FPS)
+		}
+
+		mux.HandleFunc("/"+cameraName+"/ws", func(w http.ResponseWriter, r *http.Request) {
+			wsstream.Handle(frameManager, cameraName, w, r)
+		})
+	})
+
+	frameManager.Reconcile(cfg.Server, cfg.Cameras)
+	for _, cam := range cfg.Cameras {
+		if cam.Enabled {
+			log.Printf("Camera endpoint ready: http://localhost:%s/%s", cfg.Server.Port, cam.Name)
+		}
 	}
-	log.Printf("MJPEG server listening on :%s (Serve FPS: %d, Fetch FPS: %d, Cache: %s)\n", cfg.Server.Port, cfg.Server.FPS, cfg.Server.FetchFPS, cacheStatus)
-	log.Fatal(http.ListenAndServe(":"+cfg.Server.Port, nil))
+
+	loader.OnChange(func(newCfg *config.Config) {
+		cfgRef.Store(newCfg)
+		frameManager.Reconcile(newCfg.Server, newCfg.Cameras)
+		log.Printf("config.yaml reloaded: %d camera(s)", len(newCfg.Cameras))
+	})
+
+	log.Printf("MJPEG server listening on :%s (Serve FPS: %d, Cache: %v)\n", cfg.Server.Port, cfg.Server.FPS, cfg.Server.UseCache)
+	log.Fatal(http.ListenAndServe(":"+cfg.Server.Port, frameManager))
 }
 
 // streamCameraFromCache serves cached frames to clients
@@ -154,7 +211,9 @@ func isValidJPEG(data []byte) bool {
 }
 
 // streamCameraDirect streams directly from camera without caching
-func streamCameraDirect(w http.ResponseWriter, r *http.Request, fm *frame.FrameManager, cameraName, cameraURL string, cfg *config.Config) {
+func streamCameraDirect(w http.ResponseWriter, r *http.Request, fm *frame.FrameManager, cam config.CameraConfig, cfg *config.Config) {
+	cameraName := cam.Name
+
 	// MJPEG headers
 	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary=frame")
 	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
@@ -169,6 +228,7 @@ func streamCameraDirect(w http.ResponseWriter, r *http.Request, fm *frame.FrameM
 
 	ctx := r.Context()
 	frameInterval := time.Duration(1000/cfg.Server.FPS) * time.Millisecond
+	cookieName, cookieValue := client.ParseCookie(cam.Cookie)
 
 	// Pre-allocate buffer for frame header to avoid repeated allocations
 	headerBuf := make([]byte, 0, 128)
@@ -183,7 +243,7 @@ func streamCameraDirect(w http.ResponseWriter, r *http.Request, fm *frame.FrameM
 		}
 
 		// Fetch frame directly
-		resp, err := fm.Client.GetStream(cameraURL)
+		resp, err := fm.Client.GetStream(cam.URL, cam.AuthToken, cookieName, cookieValue)
 		if err != nil {
 			log.Printf("[%s] request error: %v", cameraName, err)
 			time.Sleep(frameInterval)
@@ -241,3 +301,153 @@ func streamCameraDirect(w http.ResponseWriter, r *http.Request, fm *frame.FrameM
 		time.Sleep(frameInterval)
 	}
 }
+
+// registerHLSHandlers wires up the playlist and segment endpoints for a
+// single camera's HLS output, e.g. /rua/hls/index.m3u8.
+func registerHLSHandlers(mux *http.ServeMux, mgr *hls.Manager, cameraName string) {
+	prefix := "/" + cameraName + "/hls/"
+
+	mux.HandleFunc(prefix, func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, prefix)
+		switch {
+		case name == "index.m3u8":
+			serveHLSPlaylist(w, r, mgr, cameraName)
+		case strings.HasPrefix(name, "segment-") && strings.HasSuffix(name, ".ts"):
+			serveHLSSegment(w, r, mgr, cameraName, name)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// serveHLSPlaylist serves the live playlist for a camera. The playlist
+// itself must never be cached since it changes every segment.
+func serveHLSPlaylist(w http.ResponseWriter, r *http.Request, mgr *hls.Manager, cameraName string) {
+	playlist, err := mgr.GetPlaylist(cameraName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if r.Method == http.MethodHead {
+		return
+	}
+	io.WriteString(w, playlist)
+}
+
+// serveHLSSegment serves one completed MPEG-TS segment. Segments are
+// immutable once written, so they're marked cacheable for a day.
+func serveHLSSegment(w http.ResponseWriter, r *http.Request, mgr *hls.Manager, cameraName, name string) {
+	seq, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "segment-"), ".ts"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	data, err := mgr.GetSegment(cameraName, seq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	etag := fmt.Sprintf("%q", fmt.Sprintf("%s-%d", cameraName, seq))
+	w.Header().Set("Content-Type", "video/mp2t")
+	w.Header().Set("Cache-Control", "public, max-age=86400, immutable")
+	w.Header().Set("ETag", etag)
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(data)
+}
+
+// registerRecorderHandlers wires up the recordings index, MP4 range
+// playback, and single-snapshot endpoints for one camera.
+func registerRecorderHandlers(mux *http.ServeMux, rec *recorder.Manager, cameraName string, outputFPS int) {
+	mux.HandleFunc("/"+cameraName+"/recordings", func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := parseTimeRange(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		entries, err := rec.Index(cameraName, from, to)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(entries); err != nil {
+			log.Printf("[%s] recordings encode error: %v", cameraName, err)
+		}
+	})
+
+	mux.HandleFunc("/"+cameraName+"/view.mp4", func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := parseTimeRange(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "video/mp4")
+		if err := rec.ViewMP4(r.Context(), cameraName, from, to, outputFPS, w); err != nil {
+			log.Printf("[%s] view.mp4 error: %v", cameraName, err)
+		}
+	})
+
+	mux.HandleFunc("/"+cameraName+"/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		at := time.Now()
+		if v := r.URL.Query().Get("at"); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "invalid at: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			at = parsed
+		}
+
+		data, err := rec.SnapshotAt(cameraName, at)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write(data)
+	})
+}
+
+// parseTimeRange reads the "from"/"to" RFC3339 query parameters used by
+// the recordings and view.mp4 endpoints, defaulting to the last hour.
+func parseTimeRange(r *http.Request) (time.Time, time.Time, error) {
+	from := time.Now().Add(-time.Hour)
+	to := time.Now()
+
+	if v := r.URL.Query().Get("from"); v != "" {
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from: %w", err)
+		}
+		from = parsed
+	}
+
+	if v := r.URL.Query().Get("to"); v != "" {
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to: %w", err)
+		}
+		to = parsed
+	}
+
+	return from, to, nil
+}